@@ -0,0 +1,350 @@
+package MerkleTree
+
+import (
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"runtime"
+	"sync"
+)
+
+// Option configures a tree built with NewTreeWithBatch.
+type Option func(*batchConfig)
+
+type batchConfig struct {
+	hashPolicy func() hash.Hash
+	enableBF   bool
+	bfFP       float64
+}
+
+// WithBatchHashPolicy overrides the default SHA-256 hash policy used by
+// NewTreeWithBatch.
+func WithBatchHashPolicy(policy func() hash.Hash) Option {
+	return func(c *batchConfig) {
+		c.hashPolicy = policy
+	}
+}
+
+// WithBatchBloomFilter enables a bloom filter on every internal node of
+// a tree built with NewTreeWithBatch, sized for the given false-positive
+// rate.
+func WithBatchBloomFilter(fp float64) Option {
+	return func(c *batchConfig) {
+		c.enableBF = true
+		c.bfFP = fp
+	}
+}
+
+// NewTreeWithBatch builds a MerkleTree covering the same contents as
+// NewTree, but spreads both the hashing and the internal-node
+// construction across runtime.NumCPU() workers instead of doing either
+// on a single goroutine: contents is split into runtime.NumCPU() (or
+// len(contents), if smaller) disjoint, equally sized buckets, each built
+// bottom-up into its own
+// complete subtree on its own goroutine, and only the top
+// log2(numBuckets) levels - combining each bucket's root with its
+// neighbours - are built on the calling goroutine. For large inputs,
+// where construction cost is dominated by hashing and combining rather
+// than by any single sequential step, this keeps the whole
+// tree-building pipeline parallel instead of only the leaf-hashing
+// part of it.
+//
+// Bucketing leaves this tree's exact shape - and hence MerkleRoot() -
+// different from a NewTree built over the same contents whenever
+// bucketing requires padding; every other operation (VerifyTree,
+// VerifyContent, GetMerkleMultiProof, GetMultiProof) works the same as
+// on any other MerkleTree.
+func NewTreeWithBatch(contents []Content, opts ...Option) (*MerkleTree, error) {
+	if len(contents) == 0 {
+		return nil, errors.New("no contents")
+	}
+	cfg := &batchConfig{hashPolicy: sha256.New}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	tree := &MerkleTree{
+		hashPolicy: cfg.hashPolicy,
+	}
+	if cfg.enableBF {
+		m, k := EstimateParameters(uint(len(contents)), cfg.bfFP)
+		tree.enableBF = true
+		tree.bf_m = m
+		tree.bf_k = k
+	}
+
+	root, leaves, bucketSize, bucketRoots, err := buildVirtualTree(contents, tree, runtime.NumCPU(), 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	tree.root = root
+	tree.leaves = leaves
+	tree.rootHash = root.nodeHash
+	tree.vtBucketSize = bucketSize
+	tree.vtBucketRoots = bucketRoots
+	tree.vtRealLeafCount = uint64(len(contents))
+	return tree, nil
+}
+
+// addBatchSmallThreshold is the leaf count below which AddBatch just
+// rebuilds the tree sequentially, since the bookkeeping of a parallel
+// rebuild costs more than it saves at that size.
+const addBatchSmallThreshold = 2 * 1024
+
+// realLeafContents returns tree's leaf contents in order, skipping the
+// duplicate padding leaves NewTreeWithBatch or buildTreeWithContents may
+// have added.
+func realLeafContents(tree *MerkleTree) []Content {
+	out := make([]Content, 0, len(tree.leaves))
+	for _, leaf := range tree.leaves {
+		if leaf.isDup {
+			continue
+		}
+		out = append(out, leaf.content)
+	}
+	return out
+}
+
+// AddBatch adds many new contents to tree at once, picking one of three
+// strategies depending on the tree's current size:
+//
+//   - the tree is empty: build it the same way NewTreeWithBatch does.
+//   - the tree is small: rebuilding existing ∪ new leaves sequentially
+//     with buildTreeWithContents is cheaper than parallel setup.
+//   - the tree is large and was itself built by NewTreeWithBatch or a
+//     prior AddBatch: every bucket made up entirely of real (non-padding)
+//     leaves is reused untouched, and only the tail - the last bucket's
+//     real leaves, if any padding had to cover for it, plus the new
+//     contents - is rebuilt, using the tree's existing bucket size so the
+//     rebuilt buckets stitch back in at the same depth as the ones kept.
+//     Otherwise (no known bucket structure to extend) it falls back to
+//     a full parallel rebuild, same as the empty-tree case.
+func (tree *MerkleTree) AddBatch(contents []Content) error {
+	if len(contents) == 0 {
+		return errors.New("no contents")
+	}
+	if tree.root == nil {
+		root, leaves, bucketSize, bucketRoots, err := buildVirtualTree(contents, tree, runtime.NumCPU(), 0, 0)
+		if err != nil {
+			return err
+		}
+		tree.root = root
+		tree.leaves = leaves
+		tree.rootHash = root.nodeHash
+		tree.vtBucketSize = bucketSize
+		tree.vtBucketRoots = bucketRoots
+		tree.vtRealLeafCount = uint64(len(contents))
+		return nil
+	}
+
+	existing := realLeafContents(tree)
+
+	if len(existing) < addBatchSmallThreshold {
+		merged := append(existing, contents...)
+		root, leaves, err := buildTreeWithContents(merged, tree)
+		if err != nil {
+			return err
+		}
+		tree.root = root
+		tree.leaves = leaves
+		tree.rootHash = root.nodeHash
+		tree.vtBucketSize = 0
+		tree.vtBucketRoots = nil
+		tree.vtRealLeafCount = 0
+		return nil
+	}
+
+	if tree.vtBucketSize == 0 || tree.vtBucketRoots == nil {
+		merged := append(existing, contents...)
+		root, leaves, bucketSize, bucketRoots, err := buildVirtualTree(merged, tree, runtime.NumCPU(), 0, 0)
+		if err != nil {
+			return err
+		}
+		tree.root = root
+		tree.leaves = leaves
+		tree.rootHash = root.nodeHash
+		tree.vtBucketSize = bucketSize
+		tree.vtBucketRoots = bucketRoots
+		tree.vtRealLeafCount = uint64(len(merged))
+		return nil
+	}
+
+	bucketSize := tree.vtBucketSize
+	cleanBuckets := tree.vtRealLeafCount / bucketSize
+	tailStart := cleanBuckets * bucketSize
+
+	tail := make([]Content, 0, tree.vtRealLeafCount-tailStart+uint64(len(contents)))
+	for _, leaf := range tree.leaves[tailStart:tree.vtRealLeafCount] {
+		tail = append(tail, leaf.content)
+	}
+	tail = append(tail, contents...)
+
+	_, tailLeaves, _, tailBucketRoots, err := buildVirtualTree(tail, tree, runtime.NumCPU(), bucketSize, tailStart)
+	if err != nil {
+		return err
+	}
+
+	allBucketRoots := append(append([]*Node{}, tree.vtBucketRoots[:cleanBuckets]...), tailBucketRoots...)
+	bucketDepth := ceilLog2(bucketSize)
+	totalDepth := bucketDepth + ceilLog2(uint64(len(allBucketRoots)))
+	root, err := combineNodesAtLevel(allBucketRoots, tree, bucketDepth+1, totalDepth, 0)
+	if err != nil {
+		return err
+	}
+
+	tree.root = root
+	tree.leaves = append(append([]*Node{}, tree.leaves[:tailStart]...), tailLeaves...)
+	tree.rootHash = root.nodeHash
+	tree.vtBucketSize = bucketSize
+	tree.vtBucketRoots = allBucketRoots
+	tree.vtRealLeafCount = tailStart + uint64(len(tail))
+	return nil
+}
+
+// nextPow2 returns the smallest power of two greater than or equal to
+// n, treating n == 0 the same as n == 1.
+func nextPow2(n uint64) uint64 {
+	return uint64(1) << ceilLog2(n)
+}
+
+// combineNodesAtLevel is buildInternalNodesAtLevel, except a single
+// input node is returned as-is rather than combined with itself - the
+// case where a bucket holds exactly one leaf, or a virtual tree was
+// built with exactly one bucket.
+func combineNodesAtLevel(nodes []*Node, tree *MerkleTree, level uint, totalDepth uint, indexBase uint64) (*Node, error) {
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return buildInternalNodesAtLevel(nodes, tree, level, totalDepth, indexBase)
+}
+
+// buildVirtualTree builds a MerkleTree's root and leaves by bucketing
+// contents into numWorkers (or however many forcedBucketSize requires)
+// equally sized, disjoint runs and building each run's subtree bottom-up
+// on its own goroutine; only the combine steps above the bucket roots
+// run on the calling goroutine.
+//
+// leafIndexBase is the (level, index) leaf index the first content
+// would have if this call's output were being spliced into a larger
+// tree that already had leafIndexBase leaves to its left - AddBatch uses
+// this to give a rebuilt tail's nodes index values that continue on from
+// the buckets it's keeping. A top-level build passes 0.
+//
+// forcedBucketSize, if non-zero, fixes the number of leaves per bucket
+// instead of deriving it from numWorkers - AddBatch uses this so a
+// rebuilt tail's buckets are the same size (and so the same depth) as
+// the buckets it's keeping, which is what lets the two be stitched
+// together without touching either.
+//
+// contents is padded at its end with duplicates of its last leaf up to
+// numBuckets * bucketSize, so every bucket holds the same power-of-two
+// number of leaves, the same way buildTreeWithContents pads a single odd
+// leaf; bucketing can require padding more than one leaf's worth, and
+// can leave trailing buckets made only of padding; this trades
+// a bound on extra hashing for every bucket (and hence every goroutine)
+// covering the same number of levels.
+func buildVirtualTree(contents []Content, tree *MerkleTree, numWorkers int, forcedBucketSize uint64, leafIndexBase uint64) (*Node, []*Node, uint64, []*Node, error) {
+	total := uint64(len(contents))
+	if total == 0 {
+		return nil, nil, 0, nil, errors.New("no contents")
+	}
+
+	var numBuckets, bucketSize uint64
+	if forcedBucketSize > 0 {
+		bucketSize = forcedBucketSize
+		numBuckets = (total + bucketSize - 1) / bucketSize
+	} else {
+		numBuckets = uint64(numWorkers)
+		if numBuckets > total {
+			numBuckets = total
+		}
+		if numBuckets < 1 {
+			numBuckets = 1
+		}
+		bucketSize = nextPow2((total + numBuckets - 1) / numBuckets)
+	}
+	if numBuckets == 1 && bucketSize == 1 {
+		// A single leaf can't become a root on its own - every other
+		// path through this function ends in at least one combine.
+		// Pad it the same way buildTreeWithContents pads a lone odd
+		// leaf: with a duplicate of itself.
+		bucketSize = 2
+	}
+
+	paddedTotal := numBuckets * bucketSize
+	padded := contents
+	if paddedTotal > total {
+		padded = make([]Content, paddedTotal)
+		copy(padded, contents)
+		last := contents[len(contents)-1]
+		for i := total; i < paddedTotal; i++ {
+			padded[i] = last
+		}
+	}
+
+	bucketDepth := ceilLog2(bucketSize)
+	totalDepth := bucketDepth + ceilLog2(numBuckets)
+
+	bucketRoots := make([]*Node, numBuckets)
+	bucketLeaves := make([][]*Node, numBuckets)
+	errs := make([]error, numBuckets)
+
+	var wg sync.WaitGroup
+	for b := uint64(0); b < numBuckets; b++ {
+		wg.Add(1)
+		go func(b uint64) {
+			defer wg.Done()
+			bucketStart := leafIndexBase + b*bucketSize
+			leaves := make([]*Node, 0, bucketSize)
+			for i := b * bucketSize; i < (b+1)*bucketSize; i++ {
+				content := padded[i]
+				h, err := content.Hash()
+				if err != nil {
+					errs[b] = err
+					return
+				}
+				n := &Node{
+					tree:     tree,
+					isLeaf:   true,
+					isDup:    i >= total,
+					nodeHash: h,
+					content:  content,
+					level:    totalDepth,
+					index:    bucketStart + (i - b*bucketSize),
+				}
+				if tree.enableBF {
+					bf := New(tree.bf_m, tree.bf_k)
+					bf.Add(n.nodeHash)
+					n.bf = bf
+				}
+				leaves = append(leaves, n)
+			}
+			root, err := combineNodesAtLevel(leaves, tree, 1, totalDepth, bucketStart)
+			if err != nil {
+				errs[b] = err
+				return
+			}
+			bucketRoots[b] = root
+			bucketLeaves[b] = leaves
+		}(b)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, nil, 0, nil, err
+		}
+	}
+
+	root, err := combineNodesAtLevel(bucketRoots, tree, bucketDepth+1, totalDepth, leafIndexBase/bucketSize)
+	if err != nil {
+		return nil, nil, 0, nil, err
+	}
+
+	leaves := make([]*Node, 0, paddedTotal)
+	for _, bl := range bucketLeaves {
+		leaves = append(leaves, bl...)
+	}
+
+	return root, leaves, bucketSize, bucketRoots, nil
+}
@@ -0,0 +1,149 @@
+package MerkleTree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+type batchTestContent struct{ i int }
+
+func (c batchTestContent) Hash() ([]byte, error) {
+	h := sha256.New()
+	h.Write([]byte{byte(c.i), byte(c.i >> 8), byte(c.i >> 16)})
+	return h.Sum(nil), nil
+}
+
+func (c batchTestContent) Equals(o Content) (bool, error) {
+	return c.i == o.(batchTestContent).i, nil
+}
+
+func makeBatchTestContents(n int) []Content {
+	out := make([]Content, n)
+	for i := range out {
+		out[i] = batchTestContent{i: i}
+	}
+	return out
+}
+
+// checkTreeConsistent verifies that tree's own root recomputes from its
+// leaves, and that every real (non-dup) content round-trips through
+// GetMerkleMultiProof against that same root.
+func checkTreeConsistent(t *testing.T, tree *MerkleTree, contents []Content) {
+	t.Helper()
+	ok, err := tree.VerifyTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("VerifyTree: root doesn't match the tree's own leaves")
+	}
+
+	root := tree.MerkleRoot()
+	for _, c := range contents {
+		proof, index, err := tree.GetMerkleMultiProof(c)
+		if err != nil {
+			t.Fatalf("GetMerkleMultiProof: %v", err)
+		}
+		h, err := c.Hash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := recomputeRoot(h, proof, index); !bytes.Equal(got, root) {
+			t.Fatalf("content %v: proof does not recompute the tree's root", c)
+		}
+	}
+}
+
+// TestNewTreeWithBatchConsistent checks that a virtual, bucketed build
+// is internally self-consistent - its root recomputes from its own
+// leaves, and every leaf's proof verifies against it - across leaf
+// counts on both sides of a bucket boundary. NewTreeWithBatch pads to
+// fill out buckets, so (unlike NewTree) its root isn't expected to
+// match NewTree's for the same contents.
+func TestNewTreeWithBatchConsistent(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 7, 16, 17, 100, 257} {
+		t.Run("", func(t *testing.T) {
+			contents := makeBatchTestContents(n)
+			tree, err := NewTreeWithBatch(contents)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkTreeConsistent(t, tree, contents)
+		})
+	}
+}
+
+// TestAddBatchPartialRebuild checks that AddBatch on a large,
+// NewTreeWithBatch-built tree stays internally self-consistent and
+// covers both the original and the newly added contents, both just
+// above and just below addBatchSmallThreshold.
+func TestAddBatchPartialRebuild(t *testing.T) {
+	for _, base := range []int{addBatchSmallThreshold - 8, addBatchSmallThreshold + 8} {
+		t.Run("", func(t *testing.T) {
+			initial := makeBatchTestContents(base)
+			added := make([]Content, base)
+			for i := range added {
+				added[i] = batchTestContent{i: base + i}
+			}
+
+			tree, err := NewTreeWithBatch(initial)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tree.AddBatch(added); err != nil {
+				t.Fatal(err)
+			}
+
+			all := append(append([]Content{}, initial...), added...)
+			checkTreeConsistent(t, tree, all)
+		})
+	}
+}
+
+// BenchmarkAddBatchBulkVsRepeated compares one bulk AddBatch call
+// against the same leaves added one at a time - MerkleTree has no
+// single-item Add, so repeated single-item AddBatch([]Content{c}) calls
+// are the closest equivalent and what the bucketed rebuild in AddBatch
+// is meant to beat at scale. The base tree is rebuilt inside the timed
+// loop (AddBatch mutates it) but that setup cost is excluded via
+// StopTimer/StartTimer so each sub-benchmark measures only the append.
+func BenchmarkAddBatchBulkVsRepeated(b *testing.B) {
+	const base = 100000
+	const batch = 200
+	initial := makeBatchTestContents(base)
+	added := make([]Content, batch)
+	for i := range added {
+		added[i] = batchTestContent{i: base + i}
+	}
+
+	b.Run("bulk", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			tree, err := NewTreeWithBatch(initial)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.StartTimer()
+			if err := tree.AddBatch(added); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("repeated", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			tree, err := NewTreeWithBatch(initial)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.StartTimer()
+			for _, c := range added {
+				if err := tree.AddBatch([]Content{c}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
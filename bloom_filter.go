@@ -38,6 +38,17 @@ func From(data []uint64, k uint) *BloomFilter {
 	return &BloomFilter{m, k, bitset.From(data)}
 }
 
+// NewWithBits reconstructs a Bloom filter with an exact m, unlike From,
+// which always infers m as a multiple of 64 from len(data). This is what
+// lets a filter whose original m wasn't a multiple of 64 - the common
+// case, since EstimateParameters rarely lands on one - round-trip through
+// storage without every bit's modulo-m location shifting.
+func NewWithBits(m uint, k uint, data []uint64) *BloomFilter {
+	b := bitset.New(m)
+	copy(b.Bytes(), data)
+	return &BloomFilter{m, k, b}
+}
+
 // baseHashes returns the four hash values of data that are used to create k
 // hashes
 func baseHashes(data []byte) [4]uint64 {
@@ -212,6 +223,12 @@ func (f *BloomFilter) Equal(g *BloomFilter) bool {
 	return f.m == g.m && f.k == g.k && f.b.Equal(g.b)
 }
 
+// Bits returns the raw bitset words backing the filter, suitable for
+// reconstructing an equivalent filter with NewWithBits(Cap(), K(), Bits()).
+func (f *BloomFilter) Bits() []uint64 {
+	return f.b.Bytes()
+}
+
 // Locations returns a list of hash locations representing a data item.
 func Locations(data []byte, k uint) []uint64 {
 	locs := make([]uint64, k)
@@ -0,0 +1,83 @@
+package cache
+
+import "sync"
+
+// key identifies a node by its level (distance from the root) and its
+// left-to-right index within that level.
+type key struct {
+	level uint
+	index uint64
+}
+
+// Entry is one cached (level, index) -> hash mapping, used by Snapshot
+// and Load to move a Cache's contents to and from a persistent store.
+type Entry struct {
+	Level uint
+	Index uint64
+	Hash  []byte
+}
+
+// Cache maps (level, index) to a node hash. It is safe for concurrent
+// use.
+type Cache struct {
+	mu      sync.RWMutex
+	entries map[key][]byte
+}
+
+// New returns an empty Cache.
+func New() *Cache {
+	return &Cache{entries: make(map[key][]byte)}
+}
+
+// Put records hash for the node at (level, index).
+func (c *Cache) Put(level uint, index uint64, hash []byte) {
+	cp := make([]byte, len(hash))
+	copy(cp, hash)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key{level, index}] = cp
+}
+
+// Get returns the cached hash for (level, index), if any.
+func (c *Cache) Get(level uint, index uint64) ([]byte, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	h, ok := c.entries[key{level, index}]
+	return h, ok
+}
+
+// Delete evicts the entry at (level, index), if present.
+func (c *Cache) Delete(level uint, index uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key{level, index})
+}
+
+// Len returns the number of cached entries.
+func (c *Cache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.entries)
+}
+
+// Snapshot returns every cached entry, for persisting to storage.
+func (c *Cache) Snapshot() []Entry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entries := make([]Entry, 0, len(c.entries))
+	for k, h := range c.entries {
+		entries = append(entries, Entry{Level: k.level, Index: k.index, Hash: h})
+	}
+	return entries
+}
+
+// Load replaces the cache's contents with entries, as produced by a
+// prior Snapshot.
+func (c *Cache) Load(entries []Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[key][]byte, len(entries))
+	for _, e := range entries {
+		c.entries[key{e.Level, e.Index}] = e.Hash
+	}
+}
@@ -0,0 +1,50 @@
+// Package cache provides caching policies and storage for pre-computed
+// Merkle tree node hashes, so that proof generation can look up a
+// sibling hash instead of recomputing it on every call.
+package cache
+
+// Policy decides which levels of a tree get their node hashes cached.
+// Level is a node's distance from the root: the root is level 0, its
+// children are level 1, and so on.
+type Policy interface {
+	ShouldCacheLayer(level uint) bool
+}
+
+type allPolicy struct{}
+
+func (allPolicy) ShouldCacheLayer(level uint) bool { return true }
+
+// CacheAll caches every level of the tree.
+func CacheAll() Policy {
+	return allPolicy{}
+}
+
+type everyNPolicy struct{ n uint }
+
+// ShouldCacheLayer caches level 0, n, 2n, and so on.
+func (p everyNPolicy) ShouldCacheLayer(level uint) bool {
+	return level%p.n == 0
+}
+
+// CacheEveryN caches every nth level, starting at the root. n less than
+// 1 is treated as 1 (cache every level).
+func CacheEveryN(n uint) Policy {
+	if n < 1 {
+		n = 1
+	}
+	return everyNPolicy{n: n}
+}
+
+type topLevelsPolicy struct{ k uint }
+
+func (p topLevelsPolicy) ShouldCacheLayer(level uint) bool {
+	return level < p.k
+}
+
+// CacheTopLevels caches only the k levels closest to the root. Those
+// levels have the fewest nodes and are shared by the most proofs, so
+// this gives the best cache-hit-rate-per-byte for a fixed memory
+// budget.
+func CacheTopLevels(k uint) Policy {
+	return topLevelsPolicy{k: k}
+}
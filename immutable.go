@@ -0,0 +1,181 @@
+package MerkleTree
+
+import (
+	"errors"
+)
+
+// locateLeaf finds the leaf holding content, using the same bloom
+// descent as VerifyContent when bloom filters are enabled and falling
+// back to a linear scan otherwise. It returns a nil Node, nil error if
+// content isn't in the tree.
+func (tree *MerkleTree) locateLeaf(content Content) (*Node, error) {
+	if tree.enableBF {
+		ok, leaf, err := tree.root.bloomCheck(content)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, nil
+		}
+		return leaf, nil
+	}
+	for _, leaf := range tree.leaves {
+		ok, err := leaf.content.Equals(content)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return leaf, nil
+		}
+	}
+	return nil, nil
+}
+
+// cloneNode returns a shallow copy of n: same tree, children, content
+// and hash, but its own *Node identity. Callers that need to hand an
+// already-existing node to rebuildPath as the replacement - rather than
+// a node they just allocated - must clone it first, since rebuildPath
+// writes to the replacement's .parent at every level and n may still be
+// reachable from the original, unmutated tree.
+func cloneNode(n *Node) *Node {
+	clone := *n
+	return &clone
+}
+
+// rebuildPath clones the path from current up to the root, replacing
+// current's position with node at each step and reusing the untouched
+// sibling at every level by pointer. It never writes to a node it
+// didn't just allocate, so every node belonging to the tree prior to
+// the call - including current and its siblings - is left exactly as
+// it was. The returned Node is the new root of the cloned path.
+func (tree *MerkleTree) rebuildPath(current, node *Node) (*Node, error) {
+	for current.parent != nil {
+		parent := current.parent
+
+		var left, right *Node
+		if parent.left == current {
+			left, right = node, parent.right
+		} else {
+			left, right = parent.left, node
+		}
+
+		h := tree.hashPolicy()
+		if _, err := h.Write(append(append([]byte{}, left.nodeHash...), right.nodeHash...)); err != nil {
+			return nil, err
+		}
+		newParent := &Node{
+			tree:     tree,
+			left:     left,
+			right:    right,
+			nodeHash: h.Sum(nil),
+		}
+		// Only the freshly allocated child is re-parented; the reused
+		// sibling's parent still points at the original tree's parent,
+		// which is exactly where it belongs for that tree.
+		node.parent = newParent
+
+		if tree.enableBF {
+			bf := left.bf.Copy()
+			if err := bf.Merge(right.bf); err != nil {
+				return nil, err
+			}
+			newParent.bf = bf
+		}
+
+		node = newParent
+		current = parent
+	}
+	return node, nil
+}
+
+func (tree *MerkleTree) rememberRoot(root *Node) []byte {
+	if tree.altRoots == nil {
+		tree.altRoots = make(map[string]*Node)
+	}
+	tree.altRoots[string(root.nodeHash)] = root
+	return root.nodeHash
+}
+
+// Update replaces old with new and returns the root hash of the
+// resulting tree. It does not mutate tree: only the nodes on the path
+// from old's leaf to the root are cloned, every unchanged sibling along
+// the way is shared by pointer with the original tree, and tree itself
+// keeps pointing at its original root. The returned hash can be handed
+// to Dump to snapshot the updated tree, or simply kept as a historical
+// root.
+func (tree *MerkleTree) Update(old, new Content) ([]byte, error) {
+	leaf, err := tree.locateLeaf(old)
+	if err != nil {
+		return nil, err
+	}
+	if leaf == nil {
+		return nil, errors.New("MerkleTree: content to update not found in tree")
+	}
+
+	h, err := new.Hash()
+	if err != nil {
+		return nil, err
+	}
+	replacement := &Node{
+		tree:     tree,
+		isLeaf:   true,
+		isDup:    leaf.isDup,
+		nodeHash: h,
+		content:  new,
+	}
+	if tree.enableBF {
+		bf := New(tree.bf_m, tree.bf_k)
+		bf.Add(replacement.nodeHash)
+		replacement.bf = bf
+	}
+
+	newRoot, err := tree.rebuildPath(leaf, replacement)
+	if err != nil {
+		return nil, err
+	}
+	return tree.rememberRoot(newRoot), nil
+}
+
+// Delete removes c from the tree and returns the root hash of the
+// resulting tree, without mutating tree. c's sibling is promoted into
+// its parent's place - rather than duplicated - so the tree stays
+// balanced without reintroducing the duplicate-last-leaf padding, and
+// every node above the promoted sibling is cloned the same way Update
+// clones the path to an updated leaf.
+func (tree *MerkleTree) Delete(c Content) ([]byte, error) {
+	leaf, err := tree.locateLeaf(c)
+	if err != nil {
+		return nil, err
+	}
+	if leaf == nil {
+		return nil, errors.New("MerkleTree: content to delete not found in tree")
+	}
+	parent := leaf.parent
+	if parent == nil {
+		return nil, errors.New("MerkleTree: cannot delete the only leaf in a tree")
+	}
+
+	var sibling *Node
+	if parent.left == leaf {
+		sibling = parent.right
+	} else {
+		sibling = parent.left
+	}
+
+	// sibling still belongs to the original tree; rebuildPath (or, for a
+	// one-level tree, this function itself) writes to the replacement's
+	// .parent, so promote a clone rather than sibling itself.
+	promoted := cloneNode(sibling)
+
+	if parent.parent == nil {
+		// parent was the root; its sibling is promoted to root outright.
+		promoted.parent = nil
+		return tree.rememberRoot(promoted), nil
+	}
+
+	newRoot, err := tree.rebuildPath(parent, promoted)
+	if err != nil {
+		return nil, err
+	}
+	return tree.rememberRoot(newRoot), nil
+}
@@ -0,0 +1,75 @@
+package MerkleTree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+type immutableTestContent struct{ b byte }
+
+func (c immutableTestContent) Hash() ([]byte, error) {
+	h := sha256.New()
+	h.Write([]byte{c.b})
+	return h.Sum(nil), nil
+}
+
+func (c immutableTestContent) Equals(o Content) (bool, error) {
+	return c.b == o.(immutableTestContent).b, nil
+}
+
+// recomputeRoot folds leafHash up through proof/index the same way
+// GetMerkleMultiProof's caller is expected to: index[i] == 1 means
+// proof[i] is the right sibling.
+func recomputeRoot(leafHash []byte, proof [][]byte, index []int64) []byte {
+	cur := leafHash
+	for i := range proof {
+		h := sha256.New()
+		if index[i] == 1 {
+			h.Write(cur)
+			h.Write(proof[i])
+		} else {
+			h.Write(proof[i])
+			h.Write(cur)
+		}
+		cur = h.Sum(nil)
+	}
+	return cur
+}
+
+// TestDeleteLeavesOriginalTreeValid checks Delete's documented guarantee
+// that the receiver tree is left fully valid: its root hash must not
+// change, and a proof for an untouched leaf fetched from it afterwards
+// must still recompute to that unchanged root.
+func TestDeleteLeavesOriginalTreeValid(t *testing.T) {
+	a := immutableTestContent{0}
+	b := immutableTestContent{1}
+	c := immutableTestContent{2}
+	d := immutableTestContent{3}
+
+	tree, err := NewTree([]Content{a, b, c, d})
+	if err != nil {
+		t.Fatal(err)
+	}
+	origRoot := append([]byte{}, tree.MerkleRoot()...)
+
+	if _, err := tree.Delete(a); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(tree.MerkleRoot(), origRoot) {
+		t.Fatal("tree.MerkleRoot() changed after Delete: the receiver was mutated")
+	}
+
+	proof, index, err := tree.GetMerkleMultiProof(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bHash, err := b.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := recomputeRoot(bHash, proof, index); !bytes.Equal(got, origRoot) {
+		t.Fatal("b's proof no longer recomputes the original root: Delete corrupted a shared node")
+	}
+}
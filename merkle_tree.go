@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"hash"
+
+	"MerkleTree/cache"
 )
 
 // The object stored in the tree should implement `Content` interface.
@@ -21,10 +23,20 @@ type Node struct {
 	right *Node
 	isLeaf bool
 	isDup bool
+	// isZero marks a sentinel node standing in for an entire all-zero
+	// subtree in a sparse tree (see NewTreeWithMaxLeaves); such a node
+	// has nodeHash set but left, right and content all nil.
+	isZero bool
 	nodeHash []byte
 	content Content
 
 	bf *BloomFilter
+
+	// level and index locate the node within the tree as (distance
+	// from the root, position within that level), matching the keys
+	// used by a proof cache; see proof_cache.go.
+	level uint
+	index uint64
 }
 
 // `MerkleTree` is a core structure of this library.
@@ -40,8 +52,36 @@ type MerkleTree struct {
 	enableBF    bool
 	bf_m        uint
 	bf_k        uint
+
+	// altRoots holds the root Nodes produced by Update/Delete, keyed by
+	// their own nodeHash, so historical roots stay reachable (e.g. via
+	// Dump) even though tree.root never changes to point at them.
+	altRoots map[string]*Node
+
+	// zeroHashes and sparseDepth are set by NewTreeWithMaxLeaves.
+	// zeroHashes[i] is the hash of an all-zero subtree of 2^i leaves;
+	// sparseDepth is the fixed depth of the tree, ceil(log2(maxLeaves)).
+	zeroHashes  [][]byte
+	sparseDepth uint
+
+	// cachePolicy and proofCache are set by WithProofCache; see
+	// proof_cache.go.
+	cachePolicy cache.Policy
+	proofCache  *cache.Cache
+
+	// vtBucketSize, vtBucketRoots and vtRealLeafCount describe the
+	// bucket structure of a tree built by NewTreeWithBatch, so AddBatch
+	// can extend it without rebuilding buckets that didn't change; see
+	// batch_tree.go. vtBucketSize is 0 for a tree built any other way.
+	vtBucketSize    uint64
+	vtBucketRoots   []*Node
+	vtRealLeafCount uint64
 }
 
+// TreeOption configures a MerkleTree at construction time. It is
+// accepted by NewTree and its variants.
+type TreeOption func(*MerkleTree)
+
 //String returns a string representation of the node.
 func (node *Node) String() string {
 	return fmt.Sprintf("%t %t %v %s", node.isLeaf, node.isDup, node.nodeHash, node.content)
@@ -49,6 +89,9 @@ func (node *Node) String() string {
 
 //
 func (node *Node) verifyNode() ([]byte, error) {
+	if node.isZero {
+		return node.nodeHash, nil
+	}
 	if node.isLeaf {
 		return node.content.Hash()
 	}
@@ -70,6 +113,11 @@ func (node *Node) verifyNode() ([]byte, error) {
 }
 
 func (node *Node) calculateHash() ([]byte, error) {
+	// A sentinel's hash is always zeroHashes[level] by construction -
+	// it has no children or content to recompute it from.
+	if node.isZero {
+		return node.nodeHash, nil
+	}
 	if node.isLeaf {
 		return node.content.Hash()
 	}
@@ -123,6 +171,26 @@ func (node *Node) bloomCheck(content Content) (bool, *Node, error) {
 
 // Recursively build internal nodes in Merkle Tree.
 func buildInternalNodes(leaves []*Node, tree *MerkleTree) (*Node, error) {
+	totalDepth := ceilLog2(uint64(len(leaves)))
+	for i, leaf := range leaves {
+		leaf.level = totalDepth
+		leaf.index = uint64(i)
+	}
+	return buildInternalNodesAtLevel(leaves, tree, 1, totalDepth, 0)
+}
+
+// buildInternalNodesAtLevel is buildInternalNodes, additionally tracking
+// level - the height above the leaves of the nodes it is about to build,
+// whose distance from the root is therefore totalDepth - level - so
+// that, when tree has a proof cache installed, every node it builds can
+// be recorded into it under (distance from root, index) - and indexBase,
+// the index leaves[0] would have if it were part of a larger sibling
+// run being assembled elsewhere (see buildVirtualTree in batch_tree.go,
+// which builds several disjoint leaf runs' internal nodes in parallel
+// and needs their index values to land in one shared sequence). A
+// caller building the whole tree in one pass, as buildInternalNodes
+// does, always starts this at 0.
+func buildInternalNodesAtLevel(leaves []*Node, tree *MerkleTree, level uint, totalDepth uint, indexBase uint64) (*Node, error) {
 	nextLevelNodes := make([]*Node, 0)
 	for i := 0; i < len(leaves); i += 2 {
 		left, right := i, i + 1
@@ -147,14 +215,17 @@ func buildInternalNodes(leaves []*Node, tree *MerkleTree) (*Node, error) {
 			bf.Merge(newNode.right.bf)
 			newNode.bf = bf
 		}
+		newNode.level = totalDepth - level
+		newNode.index = indexBase/2 + uint64(len(nextLevelNodes))
 		nextLevelNodes = append(nextLevelNodes, newNode)
 		leaves[left].parent = newNode
 		leaves[right].parent = newNode
+		tree.recordCachedNode(newNode.level, newNode.index, newNode.nodeHash)
 		if len(leaves) == 2 {
 			return newNode, nil
 		}
 	}
-	return buildInternalNodes(nextLevelNodes, tree)
+	return buildInternalNodesAtLevel(nextLevelNodes, tree, level+1, totalDepth, indexBase/2)
 }
 
 // Generate a merkle tree with the given set of Contents,
@@ -210,7 +281,7 @@ func buildTreeWithContents(contents []Content, tree *MerkleTree) (*Node, []*Node
 }
 
 // constructors of Merkle Tree
-func NewTreeWithHashPolicyAndBloomFilter(contents []Content, policy func() hash.Hash, fp float64) (*MerkleTree, error) {
+func NewTreeWithHashPolicyAndBloomFilter(contents []Content, policy func() hash.Hash, fp float64, opts ...TreeOption) (*MerkleTree, error) {
 	n := len(contents)
 	m, k := EstimateParameters(uint(n), fp)
 	tree := &MerkleTree{
@@ -219,6 +290,9 @@ func NewTreeWithHashPolicyAndBloomFilter(contents []Content, policy func() hash.
 		bf_m: m,
 		bf_k: k,
 	}
+	for _, opt := range opts {
+		opt(tree)
+	}
 	root, leaves, err := buildTreeWithContents(contents, tree)
 	if err != nil {
 		return nil, err
@@ -229,14 +303,17 @@ func NewTreeWithHashPolicyAndBloomFilter(contents []Content, policy func() hash.
 	return tree, nil
 }
 
-func NewTreeWithBloomFilter(contents []Content, fp float64) (*MerkleTree, error) {
-	return NewTreeWithHashPolicyAndBloomFilter(contents, sha256.New, fp)
+func NewTreeWithBloomFilter(contents []Content, fp float64, opts ...TreeOption) (*MerkleTree, error) {
+	return NewTreeWithHashPolicyAndBloomFilter(contents, sha256.New, fp, opts...)
 }
 
-func NewTreeWithHashPolicy(contents []Content, policy func() hash.Hash) (*MerkleTree, error) {
+func NewTreeWithHashPolicy(contents []Content, policy func() hash.Hash, opts ...TreeOption) (*MerkleTree, error) {
 	tree := &MerkleTree{
 		hashPolicy: policy,
 	}
+	for _, opt := range opts {
+		opt(tree)
+	}
 	root, leaves, err := buildTreeWithContents(contents, tree)
 	if err != nil {
 		return nil, err
@@ -247,8 +324,8 @@ func NewTreeWithHashPolicy(contents []Content, policy func() hash.Hash) (*Merkle
 	return tree, nil
 }
 
-func NewTree(contents []Content) (*MerkleTree, error) {
-	return NewTreeWithHashPolicy(contents, sha256.New)
+func NewTree(contents []Content, opts ...TreeOption) (*MerkleTree, error) {
+	return NewTreeWithHashPolicy(contents, sha256.New, opts...)
 }
 
 // Merkle Tree API
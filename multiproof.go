@@ -0,0 +1,236 @@
+package MerkleTree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"sort"
+)
+
+// MultiProof is a single compact proof covering an arbitrary subset of
+// a tree's leaves, in the OpenZeppelin multi-proof layout: Hashes holds
+// the sibling hashes the verifier can't derive on its own, and Flags
+// tells it, at each merge step, whether the other input to that merge
+// comes from the next entry in Hashes (false) or from a value already
+// computed earlier in the proof or among the leaves themselves (true).
+//
+// Unlike OpenZeppelin's original, this tree's internal hash is
+// H(left || right), not a sorted, commutative pair, so the verifier
+// also needs to know which side of each merge a Hashes entry sits on:
+// HashOnRight[i] is true when Hashes[i] is the right-hand child of that
+// merge (the value carried forward from the previous level is the
+// left-hand one), false when it's the other way around. A merge whose
+// other input is itself already known (Flags[i] true) needs no such
+// bit - two known children of the same parent are always consumed left
+// before right, since left children always carry the smaller index.
+//
+// The trailing node of a level with an odd number of nodes is combined
+// with itself (buildInternalNodesAtLevel's trailing-node rule), so the
+// "other input" to that merge isn't a second, distinct known value -
+// it's the same one again. SelfMerge[i] marks that case: when
+// Flags[i] && SelfMerge[i], the verifier reuses the value it just
+// consumed instead of taking another one off its known-value queue.
+type MultiProof struct {
+	LeafIndices []uint64
+	Hashes      [][]byte
+	HashOnRight []bool
+	Flags       []bool
+	SelfMerge   []bool
+}
+
+// GetMultiProof returns a single MultiProof covering every leaf holding
+// one of contents. It locates each target leaf the same way
+// GetMerkleMultiProof does, then walks the tree level by level,
+// bottom-up: at each level the "known" nodes are the leaves requested
+// plus the parents produced by the previous level, and a sibling's hash
+// only needs to go into the proof when that sibling isn't itself known.
+// Clustered leaves therefore share most of their path, which is what
+// makes this far smaller than concatenating one single-leaf proof per
+// leaf.
+func (tree *MerkleTree) GetMultiProof(contents []Content) (*MultiProof, error) {
+	if len(contents) == 0 {
+		return nil, errors.New("MerkleTree: no contents")
+	}
+
+	leafSet := make(map[uint64]*Node, len(contents))
+	for _, c := range contents {
+		leaf, err := tree.locateLeaf(c)
+		if err != nil {
+			return nil, err
+		}
+		if leaf == nil {
+			return nil, errors.New("MerkleTree: content not found in tree")
+		}
+		leafSet[leaf.index] = leaf
+	}
+
+	indices := make([]uint64, 0, len(leafSet))
+	current := make([]*Node, 0, len(leafSet))
+	for idx, leaf := range leafSet {
+		indices = append(indices, idx)
+		current = append(current, leaf)
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+	sort.Slice(current, func(i, j int) bool { return current[i].index < current[j].index })
+
+	var hashes [][]byte
+	var hashOnRight []bool
+	var flags []bool
+	var selfMerge []bool
+
+	for {
+		if len(current) == 1 && current[0].parent == nil {
+			break
+		}
+
+		known := make(map[uint64]bool, len(current))
+		for _, n := range current {
+			known[n.index] = true
+		}
+
+		seenParent := make(map[uint64]bool)
+		next := make([]*Node, 0, (len(current)+1)/2)
+		for _, node := range current {
+			parent := node.parent
+			if parent == nil {
+				return nil, errors.New("MerkleTree: multi-proof generation reached an inconsistent node")
+			}
+			if seenParent[parent.index] {
+				continue
+			}
+			seenParent[parent.index] = true
+
+			if parent.left == parent.right {
+				// node has no distinct sibling at this level -
+				// buildInternalNodesAtLevel combined it with itself.
+				// The verifier must reuse the one value it has rather
+				// than expect a second, distinct known value.
+				flags = append(flags, true)
+				selfMerge = append(selfMerge, true)
+				next = append(next, parent)
+				continue
+			}
+
+			var sibling *Node
+			isLeftChild := parent.left == node
+			if isLeftChild {
+				sibling = parent.right
+			} else {
+				sibling = parent.left
+			}
+			if known[sibling.index] {
+				flags = append(flags, true)
+				selfMerge = append(selfMerge, false)
+			} else {
+				flags = append(flags, false)
+				selfMerge = append(selfMerge, false)
+				hashes = append(hashes, sibling.nodeHash)
+				hashOnRight = append(hashOnRight, isLeftChild)
+			}
+			next = append(next, parent)
+		}
+		sort.Slice(next, func(i, j int) bool { return next[i].index < next[j].index })
+		current = next
+	}
+
+	return &MultiProof{LeafIndices: indices, Hashes: hashes, HashOnRight: hashOnRight, Flags: flags, SelfMerge: selfMerge}, nil
+}
+
+// VerifyMultiProof checks that contents, combined through proof,
+// produces root. contents must be given in the same order as
+// proof.LeafIndices - the order GetMultiProof itself produces them in.
+// Hashing is fixed to SHA-256, whatever hash policy the tree that
+// produced the proof was built with.
+func VerifyMultiProof(root []byte, contents []Content, proof *MultiProof) (bool, error) {
+	if proof == nil {
+		return false, errors.New("MerkleTree: nil proof")
+	}
+	if len(contents) != len(proof.LeafIndices) {
+		return false, errors.New("MerkleTree: contents must match proof.LeafIndices one-for-one")
+	}
+	if len(proof.Hashes) != len(proof.HashOnRight) {
+		return false, errors.New("MerkleTree: proof.Hashes and proof.HashOnRight must be the same length")
+	}
+	if len(proof.Flags) != len(proof.SelfMerge) {
+		return false, errors.New("MerkleTree: proof.Flags and proof.SelfMerge must be the same length")
+	}
+
+	leafHashes := make([][]byte, len(contents))
+	for i, c := range contents {
+		h, err := c.Hash()
+		if err != nil {
+			return false, err
+		}
+		leafHashes[i] = h
+	}
+
+	combine := func(a, b []byte) []byte {
+		h := sha256.New()
+		h.Write(append(append([]byte{}, a...), b...))
+		return h.Sum(nil)
+	}
+
+	totalHashes := len(proof.Flags)
+	hashes := make([][]byte, totalHashes)
+	leafPos, hashPos, proofPos := 0, 0, 0
+
+	takeNext := func() ([]byte, error) {
+		if leafPos < len(leafHashes) {
+			h := leafHashes[leafPos]
+			leafPos++
+			return h, nil
+		}
+		if hashPos < totalHashes {
+			h := hashes[hashPos]
+			hashPos++
+			return h, nil
+		}
+		return nil, errors.New("MerkleTree: multi-proof is missing a known value")
+	}
+
+	for i := 0; i < totalHashes; i++ {
+		a, err := takeNext()
+		if err != nil {
+			return false, err
+		}
+
+		if proof.Flags[i] {
+			b := a
+			if !proof.SelfMerge[i] {
+				b, err = takeNext()
+				if err != nil {
+					return false, err
+				}
+			}
+			hashes[i] = combine(a, b)
+			continue
+		}
+
+		if proofPos >= len(proof.Hashes) {
+			return false, errors.New("MerkleTree: multi-proof ran out of hashes")
+		}
+		sibling := proof.Hashes[proofPos]
+		onRight := proof.HashOnRight[proofPos]
+		proofPos++
+
+		if onRight {
+			hashes[i] = combine(a, sibling)
+		} else {
+			hashes[i] = combine(sibling, a)
+		}
+	}
+
+	var computedRoot []byte
+	switch {
+	case totalHashes > 0:
+		computedRoot = hashes[totalHashes-1]
+	case len(leafHashes) > 0:
+		computedRoot = leafHashes[0]
+	case len(proof.Hashes) > 0:
+		computedRoot = proof.Hashes[0]
+	default:
+		return false, errors.New("MerkleTree: empty multi-proof")
+	}
+
+	return bytes.Equal(computedRoot, root), nil
+}
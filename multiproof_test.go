@@ -0,0 +1,124 @@
+package MerkleTree
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+type multiProofTestContent struct{ b byte }
+
+func (c multiProofTestContent) Hash() ([]byte, error) {
+	h := sha256.New()
+	h.Write([]byte{c.b})
+	return h.Sum(nil), nil
+}
+
+func (c multiProofTestContent) Equals(o Content) (bool, error) {
+	return c.b == o.(multiProofTestContent).b, nil
+}
+
+// TestMultiProofNonAdjacentLeaves round-trips GetMultiProof/
+// VerifyMultiProof over leaf subsets whose siblings aren't all on the
+// same side of their parent, which is what exercises H(left||right)'s
+// order sensitivity.
+func TestMultiProofNonAdjacentLeaves(t *testing.T) {
+	leaves := make([]Content, 8)
+	for i := range leaves {
+		leaves[i] = multiProofTestContent{b: byte(i)}
+	}
+	tree, err := NewTree(leaves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := tree.MerkleRoot()
+
+	cases := map[string][]int{
+		"adjacent pair":    {0, 1},
+		"scattered pair":   {1, 4},
+		"scattered triple": {0, 2, 7},
+		"contiguous run":   {2, 3, 4, 5},
+		"full set":         {0, 1, 2, 3, 4, 5, 6, 7},
+	}
+	for name, idxs := range cases {
+		t.Run(name, func(t *testing.T) {
+			var contents []Content
+			for _, i := range idxs {
+				contents = append(contents, leaves[i])
+			}
+			proof, err := tree.GetMultiProof(contents)
+			if err != nil {
+				t.Fatalf("GetMultiProof: %v", err)
+			}
+
+			byIndex := make(map[uint64]Content, len(idxs))
+			for _, i := range idxs {
+				byIndex[uint64(i)] = leaves[i]
+			}
+			ordered := make([]Content, len(proof.LeafIndices))
+			for i, leafIdx := range proof.LeafIndices {
+				ordered[i] = byIndex[leafIdx]
+			}
+
+			ok, err := VerifyMultiProof(root, ordered, proof)
+			if err != nil {
+				t.Fatalf("VerifyMultiProof: %v", err)
+			}
+			if !ok {
+				t.Error("valid multi-proof was rejected")
+			}
+		})
+	}
+}
+
+// TestMultiProofOddInternalLevel covers leaf counts whose internal
+// levels aren't always even, so buildInternalNodesAtLevel's
+// self-merge rule (a lone trailing node combined with itself) kicks in
+// at least once - including the single-leaf request {8} out of 9 that
+// hits it on both levels just below the root.
+func TestMultiProofOddInternalLevel(t *testing.T) {
+	const n = 9
+	leaves := make([]Content, n)
+	for i := range leaves {
+		leaves[i] = multiProofTestContent{b: byte(i)}
+	}
+	tree, err := NewTree(leaves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := tree.MerkleRoot()
+
+	cases := map[string][]int{
+		"single leaf hitting a self-merge": {8},
+		"pair spanning a self-merge level": {6, 8},
+		"most of the tree":                 {0, 1, 2, 3, 4, 5, 6, 7, 8},
+	}
+	for name, idxs := range cases {
+		t.Run(name, func(t *testing.T) {
+			var contents []Content
+			for _, i := range idxs {
+				contents = append(contents, leaves[i])
+			}
+			proof, err := tree.GetMultiProof(contents)
+			if err != nil {
+				t.Fatalf("GetMultiProof: %v", err)
+			}
+
+			byIndex := make(map[uint64]Content, len(idxs))
+			for _, i := range idxs {
+				byIndex[uint64(i)] = leaves[i]
+			}
+			ordered := make([]Content, len(proof.LeafIndices))
+			for i, leafIdx := range proof.LeafIndices {
+				ordered[i] = byIndex[leafIdx]
+			}
+
+			ok, err := VerifyMultiProof(root, ordered, proof)
+			if err != nil {
+				t.Fatalf("VerifyMultiProof: %v", err)
+			}
+			if !ok {
+				t.Error("valid multi-proof was rejected")
+			}
+		})
+	}
+}
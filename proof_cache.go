@@ -0,0 +1,183 @@
+package MerkleTree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"MerkleTree/cache"
+)
+
+// WithProofCache makes buildInternalNodes record every internal node's
+// hash into a cache.Cache as the tree is built, keyed by (level, index)
+// and filtered by policy, so GetCachedProof can look a sibling hash up
+// instead of recomputing it.
+func WithProofCache(policy cache.Policy) TreeOption {
+	return func(tree *MerkleTree) {
+		tree.cachePolicy = policy
+		tree.proofCache = cache.New()
+	}
+}
+
+// recordCachedNode is called by buildInternalNodesAtLevel for every
+// node it builds; it is a no-op on a tree without a proof cache.
+func (tree *MerkleTree) recordCachedNode(level uint, index uint64, nodeHash []byte) {
+	if tree.cachePolicy == nil || !tree.cachePolicy.ShouldCacheLayer(level) {
+		return
+	}
+	tree.proofCache.Put(level, index, nodeHash)
+}
+
+// GetCachedProof returns the Merkle path for c the same way
+// GetMerkleMultiProof does, but serves each level from the proof cache
+// when the tree's cache policy covers it, falling back to calculateHash
+// for the levels it doesn't.
+func (tree *MerkleTree) GetCachedProof(c Content) ([][]byte, []int64, error) {
+	if tree.proofCache == nil {
+		return nil, nil, errors.New("MerkleTree: tree was not built with WithProofCache")
+	}
+	leaf, err := tree.locateLeaf(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	if leaf == nil {
+		return nil, nil, nil
+	}
+
+	merklePath := make([][]byte, 0)
+	index := make([]int64, 0)
+
+	node := leaf
+	curparent := leaf.parent
+	for curparent != nil {
+		var sibling *Node
+		var side int64
+		if curparent.left == node {
+			sibling = curparent.right
+			side = 1 // right sibling
+		} else {
+			sibling = curparent.left
+			side = 0 // left sibling
+		}
+
+		h, ok := tree.proofCache.Get(sibling.level, sibling.index)
+		if !ok {
+			h, err = sibling.calculateHash()
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		merklePath = append(merklePath, h)
+		index = append(index, side)
+
+		node = curparent
+		curparent = curparent.parent
+	}
+	return merklePath, index, nil
+}
+
+// InvalidatePath evicts the cache entries for every ancestor of leaf
+// index leafIdx - O(log n) entries - so a mutation affecting that leaf
+// doesn't leave stale hashes behind for GetCachedProof to serve.
+func (tree *MerkleTree) InvalidatePath(leafIdx uint64) error {
+	if tree.proofCache == nil {
+		return errors.New("MerkleTree: tree was not built with WithProofCache")
+	}
+	if leafIdx >= uint64(len(tree.leaves)) {
+		return errors.New("MerkleTree: leaf index out of range")
+	}
+	for node := tree.leaves[leafIdx].parent; node != nil; node = node.parent {
+		tree.proofCache.Delete(node.level, node.index)
+	}
+	return nil
+}
+
+func proofCacheStorageKey(tree *MerkleTree) []byte {
+	return append([]byte("MerkleTree:proofcache:"), tree.rootHash...)
+}
+
+// Flush persists the proof cache's current contents to storage, keyed
+// by the tree's root hash.
+func (tree *MerkleTree) Flush(storage Storage) error {
+	if tree.proofCache == nil {
+		return errors.New("MerkleTree: tree was not built with WithProofCache")
+	}
+	data, err := encodeCacheEntries(tree.proofCache.Snapshot())
+	if err != nil {
+		return err
+	}
+	return storage.Put(proofCacheStorageKey(tree), data)
+}
+
+// Reload replaces the proof cache's contents with whatever Flush last
+// wrote to storage for this tree's root hash.
+func (tree *MerkleTree) Reload(storage Storage) error {
+	if tree.proofCache == nil {
+		tree.proofCache = cache.New()
+	}
+	data, err := storage.Get(proofCacheStorageKey(tree))
+	if err != nil {
+		return err
+	}
+	entries, err := decodeCacheEntries(data)
+	if err != nil {
+		return err
+	}
+	tree.proofCache.Load(entries)
+	return nil
+}
+
+func encodeCacheEntries(entries []cache.Entry) ([]byte, error) {
+	var buf bytes.Buffer
+	var countBuf [4]byte
+	binary.BigEndian.PutUint32(countBuf[:], uint32(len(entries)))
+	if _, err := buf.Write(countBuf[:]); err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		var levelBuf, indexBuf [8]byte
+		binary.BigEndian.PutUint64(levelBuf[:], uint64(e.Level))
+		binary.BigEndian.PutUint64(indexBuf[:], e.Index)
+		if _, err := buf.Write(levelBuf[:]); err != nil {
+			return nil, err
+		}
+		if _, err := buf.Write(indexBuf[:]); err != nil {
+			return nil, err
+		}
+		if err := writeBytes(&buf, e.Hash); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeCacheEntries(data []byte) ([]cache.Entry, error) {
+	r := bytes.NewReader(data)
+	var countBuf [4]byte
+	if _, err := io.ReadFull(r, countBuf[:]); err != nil {
+		return nil, err
+	}
+	count := binary.BigEndian.Uint32(countBuf[:])
+
+	entries := make([]cache.Entry, 0, count)
+	for i := uint32(0); i < count; i++ {
+		var levelBuf, indexBuf [8]byte
+		if _, err := io.ReadFull(r, levelBuf[:]); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(r, indexBuf[:]); err != nil {
+			return nil, err
+		}
+		h, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, cache.Entry{
+			Level: uint(binary.BigEndian.Uint64(levelBuf[:])),
+			Index: binary.BigEndian.Uint64(indexBuf[:]),
+			Hash:  h,
+		})
+	}
+	return entries, nil
+}
@@ -0,0 +1,326 @@
+package MerkleTree
+
+import (
+	"bytes"
+	"errors"
+	"hash"
+)
+
+// rfc6962Node is an internal node of an RFC6962Tree. Leaves are the
+// nodes whose hash was computed with the leaf domain separation prefix;
+// everything above them carries the internal-node prefix instead.
+type rfc6962Node struct {
+	hash  []byte
+	left  *rfc6962Node
+	right *rfc6962Node
+}
+
+// RFC6962Tree is a certificate-transparency style Merkle tree following
+// the construction in RFC 6962. Unlike MerkleTree it never duplicates a
+// leaf to pad an odd level - the lone right-most node of an odd level is
+// promoted unchanged - and every hash is domain separated: leaves are
+// hashed as H(0x00 || leaf) and internal nodes as H(0x01 || left || right).
+//
+// RFC6962Tree keeps a frontier of "pending" subtree roots, one per set
+// bit of the current leaf count, so Append can extend the tree and
+// recompute the root in amortized O(log n) time instead of rebuilding
+// the whole tree.
+type RFC6962Tree struct {
+	hashPolicy func() hash.Hash
+
+	leaves []*rfc6962Node
+
+	// pending[i] holds the root of a complete subtree of 2^i leaves
+	// whenever bit i of size is set, and is nil otherwise.
+	pending []*rfc6962Node
+	size    uint64
+}
+
+// NewRFC6962Tree builds an RFC6962Tree over contents by appending them
+// one at a time in order.
+func NewRFC6962Tree(contents []Content, policy func() hash.Hash) (*RFC6962Tree, error) {
+	if len(contents) == 0 {
+		return nil, errors.New("no contents")
+	}
+	tree := &RFC6962Tree{
+		hashPolicy: policy,
+	}
+	for _, c := range contents {
+		if _, err := tree.Append(c); err != nil {
+			return nil, err
+		}
+	}
+	return tree, nil
+}
+
+// leafHash returns the RFC 6962 leaf hash H(0x00 || content.Hash()).
+//
+// Content only exposes a pre-hashed hash.Hash() from the package's
+// Content interface, so the leaf-data input to the domain-separated
+// hash is the content's own hash rather than its raw bytes.
+func (tree *RFC6962Tree) leafHash(c Content) ([]byte, error) {
+	raw, err := c.Hash()
+	if err != nil {
+		return nil, err
+	}
+	h := tree.hashPolicy()
+	if _, err := h.Write([]byte{0x00}); err != nil {
+		return nil, err
+	}
+	if _, err := h.Write(raw); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// combine returns the RFC 6962 internal node hash H(0x01 || left || right).
+func (tree *RFC6962Tree) combine(left, right []byte) ([]byte, error) {
+	h := tree.hashPolicy()
+	if _, err := h.Write([]byte{0x01}); err != nil {
+		return nil, err
+	}
+	if _, err := h.Write(left); err != nil {
+		return nil, err
+	}
+	if _, err := h.Write(right); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// Append hashes c as a new right-most leaf, combines it up the pending
+// spine on every carry, and returns the new root. It runs in amortized
+// O(log n) time: most appends only touch a handful of pending slots.
+func (tree *RFC6962Tree) Append(c Content) ([]byte, error) {
+	h, err := tree.leafHash(c)
+	if err != nil {
+		return nil, err
+	}
+	leaf := &rfc6962Node{hash: h}
+	tree.leaves = append(tree.leaves, leaf)
+
+	node := leaf
+	level := 0
+	index := tree.size
+	for index&1 == 1 {
+		sibling := tree.pending[level]
+		combined, err := tree.combine(sibling.hash, node.hash)
+		if err != nil {
+			return nil, err
+		}
+		node = &rfc6962Node{hash: combined, left: sibling, right: node}
+		tree.pending[level] = nil
+		index >>= 1
+		level++
+	}
+	if level == len(tree.pending) {
+		tree.pending = append(tree.pending, nil)
+	}
+	tree.pending[level] = node
+	tree.size++
+
+	return tree.Root()
+}
+
+// Root folds the pending spine, low bit to high bit, into the current
+// root hash. It is the standard RFC 6962 combination for a tree whose
+// size is not a power of two.
+func (tree *RFC6962Tree) Root() ([]byte, error) {
+	if tree.size == 0 {
+		return nil, errors.New("empty tree has no root")
+	}
+	var acc []byte
+	for i := 0; i < len(tree.pending); i++ {
+		if tree.pending[i] == nil {
+			continue
+		}
+		if acc == nil {
+			acc = tree.pending[i].hash
+			continue
+		}
+		combined, err := tree.combine(tree.pending[i].hash, acc)
+		if err != nil {
+			return nil, err
+		}
+		acc = combined
+	}
+	return acc, nil
+}
+
+// Size returns the number of leaves appended to the tree so far.
+func (tree *RFC6962Tree) Size() uint64 {
+	return tree.size
+}
+
+// largestPowerOfTwoLessThan returns the largest k such that k is a
+// power of two and k < n, for n > 1.
+func largestPowerOfTwoLessThan(n uint64) uint64 {
+	k := uint64(1)
+	for k*2 < n {
+		k *= 2
+	}
+	return k
+}
+
+// mth computes the Merkle tree hash of the leaf range [lo, hi), as
+// defined by RFC 6962's MTH function.
+func (tree *RFC6962Tree) mth(lo, hi uint64) ([]byte, error) {
+	n := hi - lo
+	if n == 1 {
+		return tree.leaves[lo].hash, nil
+	}
+	k := largestPowerOfTwoLessThan(n)
+	left, err := tree.mth(lo, lo+k)
+	if err != nil {
+		return nil, err
+	}
+	right, err := tree.mth(lo+k, hi)
+	if err != nil {
+		return nil, err
+	}
+	return tree.combine(left, right)
+}
+
+// ConsistencyProof returns the list of node hashes that let a verifier
+// confirm a tree of size newSize is an append-only extension of a tree
+// of size oldSize, following RFC 6962's PROOF(m, D[n]) algorithm.
+func (tree *RFC6962Tree) ConsistencyProof(oldSize, newSize uint64) ([][]byte, error) {
+	if oldSize > newSize {
+		return nil, errors.New("oldSize is larger than newSize")
+	}
+	if newSize > tree.size {
+		return nil, errors.New("newSize is larger than the tree")
+	}
+	if oldSize == 0 || oldSize == newSize {
+		return [][]byte{}, nil
+	}
+	return tree.subProof(oldSize, 0, newSize, true)
+}
+
+// subProof implements RFC 6962's SubProof(m, D[n], b) over the leaf
+// range [lo, lo+n).
+func (tree *RFC6962Tree) subProof(m, lo, hi uint64, b bool) ([][]byte, error) {
+	n := hi - lo
+	if m == n {
+		if b {
+			return [][]byte{}, nil
+		}
+		h, err := tree.mth(lo, hi)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{h}, nil
+	}
+	k := largestPowerOfTwoLessThan(n)
+	if m <= k {
+		proof, err := tree.subProof(m, lo, lo+k, b)
+		if err != nil {
+			return nil, err
+		}
+		right, err := tree.mth(lo+k, hi)
+		if err != nil {
+			return nil, err
+		}
+		return append(proof, right), nil
+	}
+	proof, err := tree.subProof(m-k, lo+k, hi, false)
+	if err != nil {
+		return nil, err
+	}
+	left, err := tree.mth(lo, lo+k)
+	if err != nil {
+		return nil, err
+	}
+	return append(proof, left), nil
+}
+
+// VerifyConsistencyProof checks that newRoot, the root of a tree of
+// newSize leaves, is an append-only extension of oldRoot, the root of a
+// tree of oldSize leaves, given the hashes produced by ConsistencyProof.
+// policy must match the hash function the tree that produced the proof
+// was built with.
+func VerifyConsistencyProof(oldRoot, newRoot []byte, oldSize, newSize uint64, proof [][]byte, policy func() hash.Hash) (bool, error) {
+	if oldSize > newSize {
+		return false, errors.New("oldSize is larger than newSize")
+	}
+	if oldSize == newSize {
+		if len(proof) != 0 {
+			return false, errors.New("proof must be empty when oldSize equals newSize")
+		}
+		return bytes.Equal(oldRoot, newRoot), nil
+	}
+	if oldSize == 0 {
+		// An empty tree is trivially consistent with any extension.
+		return true, nil
+	}
+	if len(proof) == 0 {
+		return false, errors.New("empty consistency proof")
+	}
+
+	combine := func(left, right []byte) []byte {
+		h := policy()
+		h.Write([]byte{0x01})
+		h.Write(left)
+		h.Write(right)
+		return h.Sum(nil)
+	}
+
+	// verifySub replays subProof's own recursion over PROOF(oldSize,
+	// D[0:hi], b) and, for the leaf range [lo, hi), recomputes both
+	// MTH(D[lo:lo+m]) (oldHash, meaningful only on the path that started
+	// at the top-level call) and MTH(D[lo:hi]) (newHash). Mirroring
+	// subProof's own structure exactly - same base case, same split,
+	// same left/right order - is what makes this consume the proof in
+	// the order ConsistencyProof produced it.
+	idx := 0
+	var verifySub func(m, lo, hi uint64, b bool) (oldHash, newHash []byte, err error)
+	verifySub = func(m, lo, hi uint64, b bool) ([]byte, []byte, error) {
+		n := hi - lo
+		if m == n {
+			if b {
+				return oldRoot, oldRoot, nil
+			}
+			if idx >= len(proof) {
+				return nil, nil, errors.New("consistency proof too short")
+			}
+			h := proof[idx]
+			idx++
+			return h, h, nil
+		}
+		k := largestPowerOfTwoLessThan(n)
+		if m <= k {
+			subOld, subNew, err := verifySub(m, lo, lo+k, b)
+			if err != nil {
+				return nil, nil, err
+			}
+			if idx >= len(proof) {
+				return nil, nil, errors.New("consistency proof too short")
+			}
+			right := proof[idx]
+			idx++
+			return subOld, combine(subNew, right), nil
+		}
+		subOld, subNew, err := verifySub(m-k, lo+k, hi, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if idx >= len(proof) {
+			return nil, nil, errors.New("consistency proof too short")
+		}
+		left := proof[idx]
+		idx++
+		return combine(left, subOld), combine(left, subNew), nil
+	}
+
+	fn, sn, err := verifySub(oldSize, 0, newSize, true)
+	if err != nil {
+		return false, err
+	}
+	if idx != len(proof) {
+		return false, errors.New("consistency proof too long")
+	}
+	if !bytes.Equal(fn, oldRoot) {
+		return false, nil
+	}
+	return bytes.Equal(sn, newRoot), nil
+}
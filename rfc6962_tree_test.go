@@ -0,0 +1,76 @@
+package MerkleTree
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"testing"
+)
+
+type rfc6962TestContent struct{ b byte }
+
+func (c rfc6962TestContent) Hash() ([]byte, error) {
+	h := sha256.New()
+	h.Write([]byte{c.b})
+	return h.Sum(nil), nil
+}
+
+func (c rfc6962TestContent) Equals(o Content) (bool, error) {
+	return c.b == o.(rfc6962TestContent).b, nil
+}
+
+// testVerifyConsistencyProof round-trips ConsistencyProof/
+// VerifyConsistencyProof over every (oldSize, newSize) pair for trees of
+// 1 to 7 leaves built under policy, checking both that a real proof
+// verifies and that it consumes exactly the proof elements
+// ConsistencyProof produced.
+func testVerifyConsistencyProof(t *testing.T, policy func() hash.Hash) {
+	const maxLeaves = 7
+	contents := make([]Content, maxLeaves)
+	for i := range contents {
+		contents[i] = rfc6962TestContent{b: byte(i)}
+	}
+
+	full, err := NewRFC6962Tree(contents, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := make([][]byte, maxLeaves+1)
+	running := &RFC6962Tree{hashPolicy: policy}
+	for i, c := range contents {
+		r, err := running.Append(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots[i+1] = r
+	}
+
+	for oldSize := uint64(1); oldSize <= maxLeaves; oldSize++ {
+		for newSize := oldSize; newSize <= maxLeaves; newSize++ {
+			proof, err := full.ConsistencyProof(oldSize, newSize)
+			if err != nil {
+				t.Fatalf("ConsistencyProof(%d, %d): %v", oldSize, newSize, err)
+			}
+			ok, err := VerifyConsistencyProof(roots[oldSize], roots[newSize], oldSize, newSize, proof, policy)
+			if err != nil {
+				t.Fatalf("VerifyConsistencyProof(%d, %d): %v", oldSize, newSize, err)
+			}
+			if !ok {
+				t.Errorf("VerifyConsistencyProof(%d, %d): valid proof rejected", oldSize, newSize)
+			}
+		}
+	}
+}
+
+// TestVerifyConsistencyProof checks the default SHA-256 policy.
+func TestVerifyConsistencyProof(t *testing.T) {
+	testVerifyConsistencyProof(t, sha256.New)
+}
+
+// TestVerifyConsistencyProofNonDefaultPolicy checks that
+// VerifyConsistencyProof follows a non-default hash policy rather than
+// assuming SHA-256, the way RFC6962Tree itself does.
+func TestVerifyConsistencyProofNonDefaultPolicy(t *testing.T) {
+	testVerifyConsistencyProof(t, sha512.New)
+}
@@ -0,0 +1,159 @@
+package MerkleTree
+
+import (
+	"errors"
+	"hash"
+)
+
+// ceilLog2 returns the smallest d such that 2^d >= n.
+func ceilLog2(n uint64) uint {
+	d := uint(0)
+	v := uint64(1)
+	for v < n {
+		v <<= 1
+		d++
+	}
+	return d
+}
+
+// computeZeroHashes builds the table of all-zero subtree hashes for a
+// tree of the given depth: zeroHashes[0] is the zero leaf, and
+// zeroHashes[i+1] = H(zeroHashes[i] || zeroHashes[i]).
+func computeZeroHashes(depth uint, policy func() hash.Hash) ([][]byte, error) {
+	zeroHashes := make([][]byte, depth+1)
+	zeroHashes[0] = make([]byte, policy().Size())
+	for i := uint(1); i <= depth; i++ {
+		h := policy()
+		prev := zeroHashes[i-1]
+		if _, err := h.Write(append(append([]byte{}, prev...), prev...)); err != nil {
+			return nil, err
+		}
+		zeroHashes[i] = h.Sum(nil)
+	}
+	return zeroHashes, nil
+}
+
+// buildSparseNode builds the subtree of 2^level leaves starting at leaf
+// index start. Once start falls entirely past the real leaves, the
+// whole subtree is all-zero and collapses into a single sentinel node
+// instead of being materialized.
+func buildSparseNode(level uint, start uint64, leaves []*Node, tree *MerkleTree, zeroHashes [][]byte) (*Node, error) {
+	if start >= uint64(len(leaves)) {
+		return &Node{tree: tree, isZero: true, nodeHash: zeroHashes[level]}, nil
+	}
+	if level == 0 {
+		return leaves[start], nil
+	}
+
+	half := uint64(1) << (level - 1)
+	left, err := buildSparseNode(level-1, start, leaves, tree, zeroHashes)
+	if err != nil {
+		return nil, err
+	}
+	right, err := buildSparseNode(level-1, start+half, leaves, tree, zeroHashes)
+	if err != nil {
+		return nil, err
+	}
+
+	h := tree.hashPolicy()
+	if _, err := h.Write(append(append([]byte{}, left.nodeHash...), right.nodeHash...)); err != nil {
+		return nil, err
+	}
+	node := &Node{tree: tree, left: left, right: right, nodeHash: h.Sum(nil)}
+	left.parent, right.parent = node, node
+	return node, nil
+}
+
+// NewTreeWithMaxLeaves builds a sparse Merkle tree of fixed depth
+// ceil(log2(maxLeaves)). Leaf slots beyond len(contents) are treated as
+// the all-zero hash, and whole all-zero subtrees are collapsed into a
+// single sentinel node rather than materialized, so a tree with a huge
+// maxLeaves but few real contents stays cheap to build and hold in
+// memory. This is the standard "generalized merkleization with a max"
+// pattern used by SSZ/zk-friendly consumers that need a fixed-depth,
+// fixed-length proof regardless of how full the tree currently is.
+func NewTreeWithMaxLeaves(contents []Content, maxLeaves uint64, policy func() hash.Hash) (*MerkleTree, error) {
+	if maxLeaves == 0 {
+		return nil, errors.New("maxLeaves must be at least 1")
+	}
+	if uint64(len(contents)) > maxLeaves {
+		return nil, errors.New("more contents than maxLeaves allows")
+	}
+
+	depth := ceilLog2(maxLeaves)
+	zeroHashes, err := computeZeroHashes(depth, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := &MerkleTree{
+		hashPolicy:  policy,
+		zeroHashes:  zeroHashes,
+		sparseDepth: depth,
+	}
+
+	leaves := make([]*Node, 0, len(contents))
+	for _, content := range contents {
+		h, err := content.Hash()
+		if err != nil {
+			return nil, err
+		}
+		leaves = append(leaves, &Node{tree: tree, isLeaf: true, nodeHash: h, content: content})
+	}
+
+	root, err := buildSparseNode(depth, 0, leaves, tree, zeroHashes)
+	if err != nil {
+		return nil, err
+	}
+
+	tree.root = root
+	tree.rootHash = root.nodeHash
+	tree.leaves = leaves
+	return tree, nil
+}
+
+// GetProofByIndex returns the fixed-length, depth-long Merkle proof for
+// leaf index idx, whether or not that slot currently holds real
+// content. Sentinel subtrees along the path contribute zeroHashes
+// entries in place of a materialized sibling. As with
+// GetMerkleMultiProof, the proof is ordered leaf-to-root: proof[0] is
+// idx's immediate sibling, and proof[depth-1] is the topmost sibling,
+// just below the root.
+func (tree *MerkleTree) GetProofByIndex(idx uint64) ([][]byte, error) {
+	if tree.zeroHashes == nil {
+		return nil, errors.New("MerkleTree: GetProofByIndex requires a tree built with NewTreeWithMaxLeaves")
+	}
+	depth := tree.sparseDepth
+	if idx >= uint64(1)<<depth {
+		return nil, errors.New("MerkleTree: index out of range for this tree's depth")
+	}
+
+	proof := make([][]byte, depth)
+	node := tree.root
+	for level := depth; level > 0; level-- {
+		bit := (idx >> (level - 1)) & 1
+
+		var sibling *Node
+		if node != nil {
+			if bit == 0 {
+				sibling = node.right
+			} else {
+				sibling = node.left
+			}
+		}
+		if sibling != nil {
+			proof[level-1] = sibling.nodeHash
+		} else {
+			proof[level-1] = tree.zeroHashes[level-1]
+		}
+
+		if node != nil {
+			if bit == 0 {
+				node = node.left
+			} else {
+				node = node.right
+			}
+		}
+	}
+	return proof, nil
+}
@@ -0,0 +1,76 @@
+package MerkleTree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+type sparseTreeTestContent struct{ b byte }
+
+func (c sparseTreeTestContent) Hash() ([]byte, error) {
+	h := sha256.New()
+	h.Write([]byte{c.b})
+	return h.Sum(nil), nil
+}
+
+func (c sparseTreeTestContent) Equals(o Content) (bool, error) {
+	return c.b == o.(sparseTreeTestContent).b, nil
+}
+
+// foldProof combines leafHash with proof leaf-to-root, using idx's bits
+// to decide which side each proof entry sits on, and returns the result.
+func foldProof(leafHash []byte, idx uint64, proof [][]byte) []byte {
+	cur := leafHash
+	for level, sibling := range proof {
+		bit := (idx >> uint(level)) & 1
+		h := sha256.New()
+		if bit == 0 {
+			h.Write(append(append([]byte{}, cur...), sibling...))
+		} else {
+			h.Write(append(append([]byte{}, sibling...), cur...))
+		}
+		cur = h.Sum(nil)
+	}
+	return cur
+}
+
+// TestGetProofByIndex checks that every GetProofByIndex result folds,
+// leaf-to-root, to the tree's root - for both real leaves and empty
+// (all-zero) slots.
+func TestGetProofByIndex(t *testing.T) {
+	contents := []Content{
+		sparseTreeTestContent{0}, sparseTreeTestContent{1}, sparseTreeTestContent{2},
+	}
+	const maxLeaves = 8
+	tree, err := NewTreeWithMaxLeaves(contents, maxLeaves, sha256.New)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := tree.MerkleRoot()
+
+	for idx := uint64(0); idx < maxLeaves; idx++ {
+		proof, err := tree.GetProofByIndex(idx)
+		if err != nil {
+			t.Fatalf("GetProofByIndex(%d): %v", idx, err)
+		}
+		if len(proof) != int(tree.sparseDepth) {
+			t.Fatalf("GetProofByIndex(%d): got %d proof entries, want %d", idx, len(proof), tree.sparseDepth)
+		}
+
+		var leafHash []byte
+		if idx < uint64(len(contents)) {
+			leafHash, err = contents[idx].Hash()
+			if err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			leafHash = tree.zeroHashes[0]
+		}
+
+		got := foldProof(leafHash, idx, proof)
+		if !bytes.Equal(got, root) {
+			t.Errorf("GetProofByIndex(%d): folded proof does not match root", idx)
+		}
+	}
+}
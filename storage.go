@@ -0,0 +1,483 @@
+package MerkleTree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Storage is the persistence interface a MerkleTree's nodes can be
+// backed by. Each node is addressed by its own nodeHash, so Storage
+// only ever needs simple key/value semantics.
+type Storage interface {
+	Get(key []byte) ([]byte, error)
+	Put(key, value []byte) error
+	Batch() Batch
+	Close() error
+}
+
+// Batch groups a set of Storage writes so a backend can flush them
+// together instead of one at a time.
+type Batch interface {
+	Put(key, value []byte)
+	Write() error
+}
+
+// ErrNotFound is returned by Storage.Get when key has no value.
+var ErrNotFound = errors.New("MerkleTree: key not found")
+
+// memoryStorage is an in-memory Storage backed by a map, useful for
+// tests and for snapshots that never leave the process.
+type memoryStorage struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+// NewMemoryStorage returns a Storage that keeps everything in memory.
+func NewMemoryStorage() Storage {
+	return &memoryStorage{data: make(map[string][]byte)}
+}
+
+func (s *memoryStorage) Get(key []byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.data[string(key)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := make([]byte, len(v))
+	copy(cp, v)
+	return cp, nil
+}
+
+func (s *memoryStorage) Put(key, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := make([]byte, len(value))
+	copy(cp, value)
+	s.data[string(key)] = cp
+	return nil
+}
+
+func (s *memoryStorage) Batch() Batch {
+	return &memoryBatch{storage: s}
+}
+
+func (s *memoryStorage) Close() error {
+	return nil
+}
+
+type memoryBatch struct {
+	storage *memoryStorage
+	writes  [][2][]byte
+}
+
+func (b *memoryBatch) Put(key, value []byte) {
+	b.writes = append(b.writes, [2][]byte{key, value})
+}
+
+func (b *memoryBatch) Write() error {
+	for _, kv := range b.writes {
+		if err := b.storage.Put(kv[0], kv[1]); err != nil {
+			return err
+		}
+	}
+	b.writes = nil
+	return nil
+}
+
+// Serializable is implemented by Content types that can round-trip
+// through Dump and ImportDump without being re-derived from anything
+// other than their own bytes. On its own, Serializable isn't enough for
+// ImportDump to reconstruct a leaf - with no registered type name, it
+// has no factory to call Unmarshal on - so a Content must also
+// implement TypedContent to actually be dumpable; serializeNode returns
+// an error rather than silently dropping the content of one that isn't.
+type Serializable interface {
+	Content
+	Marshal() ([]byte, error)
+	Unmarshal(data []byte) error
+}
+
+// TypedContent lets a Serializable Content report the name it was
+// registered under with RegisterContentType, so Dump can tag the leaf
+// record that holds it and ImportDump knows which factory to use when
+// reading it back.
+type TypedContent interface {
+	Serializable
+	ContentType() string
+}
+
+var contentFactories = struct {
+	mu sync.RWMutex
+	m  map[string]func() Serializable
+}{m: make(map[string]func() Serializable)}
+
+// RegisterContentType makes a Serializable Content type importable by
+// ImportDump. name must match the value the type's ContentType method
+// returns.
+func RegisterContentType(name string, factory func() Serializable) {
+	contentFactories.mu.Lock()
+	defer contentFactories.mu.Unlock()
+	contentFactories.m[name] = factory
+}
+
+func lookupContentFactory(name string) (func() Serializable, bool) {
+	contentFactories.mu.RLock()
+	defer contentFactories.mu.RUnlock()
+	f, ok := contentFactories.m[name]
+	return f, ok
+}
+
+func writeBytes(w io.Writer, b []byte) error {
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], uint32(len(b)))
+	if _, err := w.Write(lenBuf[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+func readBytes(r io.Reader) ([]byte, error) {
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(lenBuf[:])
+	if n == 0 {
+		return nil, nil
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// nodeRecord is the on-disk form of a Node: {isLeaf, isDup, leftHash,
+// rightHash, contentBytes, bloomBits}, as produced by serializeNode and
+// consumed by parseNodeRecord.
+type nodeRecord struct {
+	isLeaf      bool
+	isDup       bool
+	nodeHash    []byte
+	leftHash    []byte
+	rightHash   []byte
+	contentType string
+	contentData []byte
+	bloomM      uint
+	bloomK      uint
+	bloomWords  []uint64
+}
+
+func serializeNode(node *Node) ([]byte, error) {
+	var buf bytes.Buffer
+
+	var flags byte
+	if node.isLeaf {
+		flags |= 1
+	}
+	if node.isDup {
+		flags |= 2
+	}
+	if err := buf.WriteByte(flags); err != nil {
+		return nil, err
+	}
+	if err := writeBytes(&buf, node.nodeHash); err != nil {
+		return nil, err
+	}
+
+	if node.isLeaf {
+		contentType := ""
+		var contentData []byte
+		if tc, ok := node.content.(TypedContent); ok {
+			contentType = tc.ContentType()
+			data, err := tc.Marshal()
+			if err != nil {
+				return nil, err
+			}
+			contentData = data
+		} else if _, ok := node.content.(Serializable); ok {
+			return nil, fmt.Errorf("MerkleTree: content %T implements Serializable but not TypedContent, so it has no registered name for ImportDump to reconstruct it under", node.content)
+		}
+		if err := writeBytes(&buf, []byte(contentType)); err != nil {
+			return nil, err
+		}
+		if err := writeBytes(&buf, contentData); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := writeBytes(&buf, node.left.nodeHash); err != nil {
+			return nil, err
+		}
+		if err := writeBytes(&buf, node.right.nodeHash); err != nil {
+			return nil, err
+		}
+	}
+
+	if node.bf != nil {
+		if err := buf.WriteByte(1); err != nil {
+			return nil, err
+		}
+		var mBuf [4]byte
+		binary.BigEndian.PutUint32(mBuf[:], uint32(node.bf.Cap()))
+		if _, err := buf.Write(mBuf[:]); err != nil {
+			return nil, err
+		}
+		var kBuf [4]byte
+		binary.BigEndian.PutUint32(kBuf[:], uint32(node.bf.K()))
+		if _, err := buf.Write(kBuf[:]); err != nil {
+			return nil, err
+		}
+		words := node.bf.Bits()
+		bits := make([]byte, len(words)*8)
+		for i, word := range words {
+			binary.BigEndian.PutUint64(bits[i*8:], word)
+		}
+		if err := writeBytes(&buf, bits); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := buf.WriteByte(0); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func parseNodeRecord(data []byte) (*nodeRecord, error) {
+	r := bytes.NewReader(data)
+
+	flags, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	rec := &nodeRecord{
+		isLeaf: flags&1 != 0,
+		isDup:  flags&2 != 0,
+	}
+	if rec.nodeHash, err = readBytes(r); err != nil {
+		return nil, err
+	}
+
+	if rec.isLeaf {
+		contentType, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		rec.contentType = string(contentType)
+		if rec.contentData, err = readBytes(r); err != nil {
+			return nil, err
+		}
+	} else {
+		if rec.leftHash, err = readBytes(r); err != nil {
+			return nil, err
+		}
+		if rec.rightHash, err = readBytes(r); err != nil {
+			return nil, err
+		}
+	}
+
+	bfPresent, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if bfPresent == 1 {
+		var mBuf [4]byte
+		if _, err := io.ReadFull(r, mBuf[:]); err != nil {
+			return nil, err
+		}
+		rec.bloomM = uint(binary.BigEndian.Uint32(mBuf[:]))
+		var kBuf [4]byte
+		if _, err := io.ReadFull(r, kBuf[:]); err != nil {
+			return nil, err
+		}
+		rec.bloomK = uint(binary.BigEndian.Uint32(kBuf[:]))
+		bits, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		rec.bloomWords = make([]uint64, len(bits)/8)
+		for i := range rec.bloomWords {
+			rec.bloomWords[i] = binary.BigEndian.Uint64(bits[i*8:])
+		}
+	}
+
+	return rec, nil
+}
+
+func (tree *MerkleTree) findNode(nodeHash []byte) *Node {
+	if n := findNodeByHash(tree.root, nodeHash); n != nil {
+		return n
+	}
+	for _, altRoot := range tree.altRoots {
+		if n := findNodeByHash(altRoot, nodeHash); n != nil {
+			return n
+		}
+	}
+	return nil
+}
+
+func findNodeByHash(node *Node, nodeHash []byte) *Node {
+	if node == nil {
+		return nil
+	}
+	if bytes.Equal(node.nodeHash, nodeHash) {
+		return node
+	}
+	if node.isLeaf {
+		return nil
+	}
+	if n := findNodeByHash(node.left, nodeHash); n != nil {
+		return n
+	}
+	return findNodeByHash(node.right, nodeHash)
+}
+
+// Dump walks the subtree rooted at root - or tree.rootHash when root is
+// nil - serializing every node depth-first, children before parents, to
+// w. Because a parent's record only references its children by hash,
+// the stream can be replayed by ImportDump in one pass, checking each
+// record against the hashes it references rather than rebuilding the
+// tree from its contents; passing a historical root lets a caller snapshot an arbitrary subtree
+// rather than just the current one.
+func (tree *MerkleTree) Dump(root []byte, w io.Writer) error {
+	target := root
+	if target == nil {
+		target = tree.rootHash
+	}
+	node := tree.findNode(target)
+	if node == nil {
+		return fmt.Errorf("MerkleTree: no node with hash %x in this tree", target)
+	}
+	return dumpNode(node, w)
+}
+
+func dumpNode(node *Node, w io.Writer) error {
+	if !node.isLeaf {
+		if err := dumpNode(node.left, w); err != nil {
+			return err
+		}
+		if err := dumpNode(node.right, w); err != nil {
+			return err
+		}
+	}
+	data, err := serializeNode(node)
+	if err != nil {
+		return err
+	}
+	return writeBytes(w, data)
+}
+
+// ImportDump reconstructs a MerkleTree from a stream written by Dump,
+// verifying every internal node's hash against its children, and every
+// typed leaf's content hash against its stored nodeHash, as it loads. It
+// assumes the dumped tree used the default SHA-256 hash policy. Each
+// node is also written into storage, keyed by its own nodeHash, so a
+// later load can fetch nodes directly instead of replaying the stream.
+func ImportDump(r io.Reader, storage Storage) (*MerkleTree, error) {
+	tree := &MerkleTree{hashPolicy: sha256.New}
+	byHash := make(map[string]*Node)
+	batch := storage.Batch()
+
+	var lastNode *Node
+	for {
+		data, err := readBytes(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rec, err := parseNodeRecord(data)
+		if err != nil {
+			return nil, err
+		}
+
+		node := &Node{
+			tree:     tree,
+			isLeaf:   rec.isLeaf,
+			isDup:    rec.isDup,
+			nodeHash: rec.nodeHash,
+		}
+
+		if rec.isLeaf {
+			if rec.contentType != "" {
+				factory, ok := lookupContentFactory(rec.contentType)
+				if !ok {
+					return nil, fmt.Errorf("MerkleTree: no content factory registered for type %q", rec.contentType)
+				}
+				content := factory()
+				if err := content.Unmarshal(rec.contentData); err != nil {
+					return nil, err
+				}
+				h, err := content.Hash()
+				if err != nil {
+					return nil, err
+				}
+				if !bytes.Equal(h, rec.nodeHash) {
+					return nil, errors.New("MerkleTree: leaf content hash does not match its stored nodeHash")
+				}
+				node.content = content
+			}
+		} else {
+			left, ok := byHash[string(rec.leftHash)]
+			if !ok {
+				return nil, errors.New("MerkleTree: left child must appear before its parent in the dump")
+			}
+			right, ok := byHash[string(rec.rightHash)]
+			if !ok {
+				return nil, errors.New("MerkleTree: right child must appear before its parent in the dump")
+			}
+			h := sha256.New()
+			h.Write(append(append([]byte{}, left.nodeHash...), right.nodeHash...))
+			if !bytes.Equal(h.Sum(nil), rec.nodeHash) {
+				return nil, errors.New("MerkleTree: node hash does not match its children")
+			}
+			node.left, node.right = left, right
+			left.parent, right.parent = node, node
+		}
+
+		if len(rec.bloomWords) > 0 {
+			node.bf = NewWithBits(rec.bloomM, rec.bloomK, rec.bloomWords)
+			tree.enableBF = true
+			tree.bf_m = node.bf.Cap()
+			tree.bf_k = node.bf.K()
+		}
+
+		byHash[string(rec.nodeHash)] = node
+		batch.Put(rec.nodeHash, data)
+		lastNode = node
+	}
+
+	if lastNode == nil {
+		return nil, errors.New("MerkleTree: empty dump")
+	}
+	if err := batch.Write(); err != nil {
+		return nil, err
+	}
+
+	tree.root = lastNode
+	tree.rootHash = lastNode.nodeHash
+	tree.leaves = make([]*Node, 0)
+	collectLeaves(lastNode, &tree.leaves)
+	return tree, nil
+}
+
+func collectLeaves(node *Node, out *[]*Node) {
+	if node == nil {
+		return
+	}
+	if node.isLeaf {
+		*out = append(*out, node)
+		return
+	}
+	collectLeaves(node.left, out)
+	collectLeaves(node.right, out)
+}
@@ -0,0 +1,87 @@
+package MerkleTree
+
+import (
+	bolt "go.etcd.io/bbolt"
+)
+
+var boltBucket = []byte("MerkleTree")
+
+// boltStorage is a Storage backed by a single BoltDB bucket.
+type boltStorage struct {
+	db *bolt.DB
+}
+
+// NewBoltStorage opens (creating if necessary) a BoltDB database at
+// path and returns a Storage backed by it.
+func NewBoltStorage(path string) (Storage, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &boltStorage{db: db}, nil
+}
+
+func (s *boltStorage) Get(key []byte) ([]byte, error) {
+	var value []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(boltBucket).Get(key)
+		if v == nil {
+			return ErrNotFound
+		}
+		value = make([]byte, len(v))
+		copy(value, v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (s *boltStorage) Put(key, value []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put(key, value)
+	})
+}
+
+func (s *boltStorage) Batch() Batch {
+	return &boltBatch{storage: s}
+}
+
+func (s *boltStorage) Close() error {
+	return s.db.Close()
+}
+
+type boltBatch struct {
+	storage *boltStorage
+	writes  [][2][]byte
+}
+
+func (b *boltBatch) Put(key, value []byte) {
+	b.writes = append(b.writes, [2][]byte{key, value})
+}
+
+func (b *boltBatch) Write() error {
+	err := b.storage.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		for _, kv := range b.writes {
+			if err := bucket.Put(kv[0], kv[1]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	b.writes = nil
+	return nil
+}
@@ -0,0 +1,60 @@
+package MerkleTree
+
+import (
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// levelDBStorage is a Storage backed by a LevelDB database.
+type levelDBStorage struct {
+	db *leveldb.DB
+}
+
+// NewLevelDBStorage opens (creating if necessary) a LevelDB database at
+// path and returns a Storage backed by it.
+func NewLevelDBStorage(path string) (Storage, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &levelDBStorage{db: db}, nil
+}
+
+func (s *levelDBStorage) Get(key []byte) ([]byte, error) {
+	v, err := s.db.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (s *levelDBStorage) Put(key, value []byte) error {
+	return s.db.Put(key, value, nil)
+}
+
+func (s *levelDBStorage) Batch() Batch {
+	return &levelDBBatch{db: s.db, batch: new(leveldb.Batch)}
+}
+
+func (s *levelDBStorage) Close() error {
+	return s.db.Close()
+}
+
+type levelDBBatch struct {
+	db    *leveldb.DB
+	batch *leveldb.Batch
+}
+
+func (b *levelDBBatch) Put(key, value []byte) {
+	b.batch.Put(key, value)
+}
+
+func (b *levelDBBatch) Write() error {
+	if err := b.db.Write(b.batch, nil); err != nil {
+		return err
+	}
+	b.batch.Reset()
+	return nil
+}